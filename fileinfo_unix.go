@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the (device, inode) pair identifying the underlying
+// file, used to tell a rotated/renamed file apart from the one we opened.
+func fileIdentity(info os.FileInfo) (device uint64, inode uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(stat.Dev), stat.Ino
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// fileIdentity has no (device, inode) equivalent on Windows; rotation there
+// is still caught by the os.SameFile comparison in checkRotation.
+func fileIdentity(info os.FileInfo) (device uint64, inode uint64) {
+	return 0, 0
+}
@@ -7,7 +7,14 @@ import (
 	"fmt"
 	"io"
 	"os" // for File and friends
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
 )
 
 type Harvester struct {
@@ -17,19 +24,58 @@ type Harvester struct {
 	Offset     int64
 	FinishChan chan int64
 
-	file *os.File /* the file being watched */
-	size int64
-	info *os.FileInfo /* most recent size of the file being watched */
+	file      *os.File        /* the file being watched */
+	rawReader *countingReader /* counts pre-decode bytes pulled from file, sits under reader */
+	reader    *bufio.Reader   /* long-lived decoded reader over file, kept across reads */
+	size      int64
+	info      *os.FileInfo /* most recent size of the file being watched */
+	decoder   encoding.Encoding
+
+	lineRawStart int64 /* h.rawReader.n as of the start of the line currently being assembled */
+
+	device uint64 /* identity of the open fd, for rotation detection */
+	inode  uint64
+
+	backoffCurrent time.Duration
+
+	// Pending multiline event being assembled, when FileConfig.Multiline is set.
+	mlPending      bool
+	mlBuffer       []byte
+	mlLines        int
+	mlStartOffset  int64
+	mlAnyTruncated bool
+	mlLastAppend   time.Time
+
+	done          chan struct{} // closed by whichever close_* supervisor fires first
+	closeOnce     sync.Once
+	closeReason   string
+	lastReadNanos int64 // unix nanos, accessed via atomic from supervisor goroutines
+
+	stats *harvesterStats
 }
 
 var FILE_TRUNCATED = errors.New("file has been truncated")
+var FILE_ROTATED = errors.New("file has been rotated")
+
+// Defaults applied when the corresponding FileConfig field is unset.
+const (
+	defaultBackoff           = 1 * time.Second
+	defaultMaxBackoff        = 10 * time.Second
+	defaultBackoffFactor     = 2
+	defaultLineBufferBytes   = 16 * 1024
+	defaultMultilineMaxLines = 500
+	defaultMultilineTimeout  = 5 * time.Second
+	defaultMultilineMatch    = "after"
+)
 
 func (h *Harvester) Harvest(output chan *FileEvent) {
 
 	// On completion, push offset so we can continue where we left off if we relaunch on the same file
 	defer func() { h.FinishChan <- h.Offset }()
 
-	var line uint64 = 0 // Ask registrar about the line number
+	h.decoder = h.resolveEncoding()
+	h.applyBackoffDefaults()
+	h.applyMultilineDefaults()
 
 	if h.IsTracked {
 		emit("harvest: %q position:%d\n", h.Path, h.Offset)
@@ -39,53 +85,475 @@ func (h *Harvester) Harvest(output chan *FileEvent) {
 		emit("harvest: %q\n", h.Path)
 	}
 
-	buffer := new(bytes.Buffer)
+	// Open once and hold the fd for the harvester's whole lifetime, instead of
+	// re-opening by path on every line: that was expensive and only detected
+	// truncation, never copy-truncate or rename-based rotation.
+	h.open()
+	defer h.file.Close()
+	h.lineRawStart = h.rawReader.n
+
+	h.done = make(chan struct{})
+	h.touchLastRead()
+
+	h.stats = registerHarvesterStats(h.Path)
+	defer unregisterHarvesterStats(h.Path, h.stats)
+
+	var supervisors sync.WaitGroup
+	h.startSupervisors(&supervisors)
+
+	supervisors.Add(1)
+	go h.runStatsUpdater(&supervisors)
+
+	h.readLoop(output)
+
+	h.stop("") // no-op if a supervisor already closed h.done
+	supervisors.Wait()
+}
+
+// readLoop pulls lines (or assembled multiline events) off the file and
+// ships them to output until the file is exhausted for good, a close_*
+// condition fires (h.done), or something goes wrong.
+func (h *Harvester) readLoop(output chan *FileEvent) {
+	initialBufferBytes := h.FileConfig.LineBufferBytes
+	if initialBufferBytes <= 0 {
+		initialBufferBytes = defaultLineBufferBytes
+	}
+	buffer := bytes.NewBuffer(make([]byte, 0, initialBufferBytes))
+
+	var line uint64 = 0 // Ask registrar about the line number
+
+	// Every physical line already advances h.Offset once read, whether or not
+	// it's been folded into an assembled multiline event yet. Without this,
+	// the top select's <-h.done return below (close_renamed/close_removed/
+	// close_after_interval/close_inactive firing mid-assembly) would push
+	// h.FinishChan past a pending event that was never flushed, losing it on
+	// resume. flushMultiline is a no-op when nothing is pending, so this is
+	// harmless on the paths that already flush explicitly before returning.
+	defer h.flushMultiline(output, &line, false)
 
-	var read_timeout = 10 * time.Second
-	last_read_time := time.Now()
 	for {
-		text, bytesread, err := h.readline(buffer, read_timeout)
+		select {
+		case <-h.done:
+			return
+		default:
+		}
+
+		text, bytesread, truncated, err := h.readline(buffer, h.eofTimeout())
 
 		if err != nil {
 			if err == FILE_TRUNCATED {
 				emit("File truncated, seeking to beginning: %s\n", h.Path)
+				h.flushMultiline(output, &line, false)
 				h.Offset = 0
+				h.file.Seek(0, os.SEEK_SET)
+				h.rawReader = &countingReader{r: h.file, n: 0}
+				h.reader = bufio.NewReader(transform.NewReader(h.rawReader, h.decoder.NewDecoder()))
+				h.lineRawStart = 0
+				// buffer may still hold a newline-less partial line read
+				// before truncation was detected; readline() only notices
+				// truncation on its next EOF, after already writing into it.
+				// Left alone, that stale prefix would glue onto the first
+				// line read from the truncated file.
+				buffer.Reset()
 				continue
+			} else if err == FILE_ROTATED {
+				h.drainRemaining(output, &line)
+				h.flushMultiline(output, &line, false)
+				h.stop(fmt.Sprintf("file rotated, handing off %s (was device:%d inode:%d)", h.Path, h.device, h.inode))
+				return
 			} else if err == io.EOF {
 				// timed out waiting for data, got eof.
-				if age := time.Since(last_read_time); age > h.FileConfig.deadtime {
-					// if last_read_time was more than dead time, this file is probably
-					// dead. Stop watching it.
-					emit("Stopping harvest of %s; last change was %v ago\n", h.Path, age)
-					return
+				if h.mlPending && time.Since(h.mlLastAppend) > h.FileConfig.Multiline.Timeout {
+					h.flushMultiline(output, &line, false)
 				}
 				continue
 			} else {
-				emit("Unexpected state reading from %s; error: %s\n", h.Path, err)
+				h.flushMultiline(output, &line, false)
+				h.stop(fmt.Sprintf("unexpected state reading from %s; error: %s", h.Path, err))
 				return
 			}
 		}
-		last_read_time = time.Now()
+		h.touchLastRead()
+		h.stats.recordRead(bytesread, h.lastReadTime())
 
-		line++
+		startOffset := h.Offset
+
+		if h.multilineEnabled() {
+			h.Offset += int64(bytesread)
+			h.stats.recordOffset(h.Offset, h.backoffCurrent)
+			if !h.appendLineToMultiline(output, &line, startOffset, *text, truncated) {
+				return
+			}
+		} else {
+			line++
+			event := &FileEvent{
+				Source:    &h.Path,
+				Offset:    startOffset,
+				Line:      line,
+				Text:      text,
+				Fields:    &h.FileConfig.Fields,
+				Truncated: truncated,
+				Device:    h.device,
+				Inode:     h.inode,
+				fileinfo:  h.info,
+			}
+			// Only advance h.Offset once the event is actually confirmed
+			// delivered: if send loses the race to h.done (a stalled
+			// publisher during shutdown), h.FinishChan must still report the
+			// offset of the last line that made it out, not this one.
+			if !h.send(output, event) {
+				return
+			}
+			h.Offset += int64(bytesread)
+			h.stats.recordOffset(h.Offset, h.backoffCurrent)
+			h.stats.recordShipped()
+		}
+	} /* forever */
+}
+
+// defaultEOFTimeout bounds how long a single readline call blocks waiting on
+// an idle file before returning io.EOF to the loop above, when there's no
+// more pressing deadline to honor.
+const defaultEOFTimeout = 10 * time.Second
+
+// eofTimeout returns how long the next readline call should wait for data
+// before giving up. Normally that's defaultEOFTimeout, but while a multiline
+// event is being assembled it's capped at whatever's left of
+// FileConfig.Multiline.Timeout, so a partially-built event actually flushes
+// close to its configured deadline instead of waiting on the much longer,
+// unrelated default.
+func (h *Harvester) eofTimeout() time.Duration {
+	if !h.mlPending {
+		return defaultEOFTimeout
+	}
+	remaining := h.FileConfig.Multiline.Timeout - time.Since(h.mlLastAppend)
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining < defaultEOFTimeout {
+		return remaining
+	}
+	return defaultEOFTimeout
+}
+
+// runStatsUpdater recomputes the EWMA line/byte speeds on a fixed tick and
+// keeps the registry's view of the current file size fresh for lag
+// reporting. It outlives nothing else in the harvester's shutdown sequence:
+// it exits (after one last update) as soon as h.done closes.
+func (h *Harvester) runStatsUpdater(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(statsTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.done:
+			h.refreshStatsSize()
+			h.stats.tick(time.Now())
+			return
+		case now := <-ticker.C:
+			h.refreshStatsSize()
+			h.stats.tick(now)
+		}
+	}
+}
+
+func (h *Harvester) refreshStatsSize() {
+	if info, err := h.file.Stat(); err == nil {
+		h.stats.recordSize(info.Size())
+	}
+}
+
+// send ships an event downstream, but aborts as soon as any close_*
+// supervisor fires instead of blocking forever on a stalled publisher.
+func (h *Harvester) send(output chan *FileEvent, event *FileEvent) bool {
+	select {
+	case output <- event:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// stop closes h.done, waking every supervisor and the read loop's blocked
+// send (if any). Safe to call more than once or concurrently; only the
+// first call's reason is logged.
+func (h *Harvester) stop(reason string) {
+	h.closeOnce.Do(func() {
+		h.closeReason = reason
+		if reason != "" {
+			emit("Closing harvester for %s: %s\n", h.Path, reason)
+		}
+		close(h.done)
+	})
+}
+
+func (h *Harvester) touchLastRead() {
+	atomic.StoreInt64(&h.lastReadNanos, time.Now().UnixNano())
+}
+
+func (h *Harvester) lastReadTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&h.lastReadNanos))
+}
+
+// supervisorPollInterval picks how often a supervisor re-checks its
+// condition: frequent enough to notice promptly, not so frequent it busy-polls.
+func supervisorPollInterval(d time.Duration) time.Duration {
+	p := d / 4
+	if p < 250*time.Millisecond {
+		p = 250 * time.Millisecond
+	}
+	if p > 30*time.Second {
+		p = 30 * time.Second
+	}
+	return p
+}
+
+// runSupervisor polls check on an interval until it returns true (closing
+// h.done with reason) or h.done is closed by someone else first.
+func (h *Harvester) runSupervisor(wg *sync.WaitGroup, interval time.Duration, check func() bool, reason string) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+			if check() {
+				h.stop(reason)
+				return
+			}
+		}
+	}
+}
+
+// startSupervisors launches one goroutine per configured close_* condition.
+// Unlike the read loop, these run independently of it, so a stalled output
+// channel (publisher down, network partition) can't keep a dead, renamed, or
+// removed file pinned open forever.
+func (h *Harvester) startSupervisors(wg *sync.WaitGroup) {
+	startedAt := time.Now()
+
+	if d := h.FileConfig.deadtime; d > 0 {
+		wg.Add(1)
+		go h.runSupervisor(wg, supervisorPollInterval(d), func() bool {
+			return time.Since(h.lastReadTime()) > d
+		}, fmt.Sprintf("close_inactive: no data read from %s in the last %v", h.Path, d))
+	}
+
+	if h.FileConfig.CloseRenamed {
+		wg.Add(1)
+		go h.runSupervisor(wg, closeCheckInterval, func() bool {
+			rotated, _ := h.checkRotation()
+			return rotated
+		}, fmt.Sprintf("close_renamed: %s now points at a different file", h.Path))
+	}
+
+	if h.FileConfig.CloseRemoved {
+		wg.Add(1)
+		go h.runSupervisor(wg, closeCheckInterval, func() bool {
+			_, removed := h.checkRotation()
+			return removed
+		}, fmt.Sprintf("close_removed: %s no longer exists", h.Path))
+	}
+
+	if d := h.FileConfig.CloseAfterInterval; d > 0 {
+		wg.Add(1)
+		go h.runSupervisor(wg, supervisorPollInterval(d), func() bool {
+			return time.Since(startedAt) > d
+		}, fmt.Sprintf("close_after_interval: %v elapsed harvesting %s", d, h.Path))
+	}
+}
+
+// closeCheckInterval paces the close_renamed and close_removed supervisors,
+// which stat the filesystem on every tick.
+const closeCheckInterval = 1 * time.Second
+
+// appendLineToMultiline folds one physical line, already split by readline,
+// into the multiline event under assembly per FileConfig.Multiline. See
+// flushMultiline for when the assembled event actually ships. Returns false
+// as soon as a flush is aborted by h.done, meaning the caller should stop.
+func (h *Harvester) appendLineToMultiline(output chan *FileEvent, line *uint64, offset int64, text string, truncated bool) bool {
+	m := h.FileConfig.Multiline
+	matches := m.Pattern.MatchString(text) != m.Negate
+
+	switch m.Match {
+	case "before":
+		if h.mlPending {
+			h.appendMultiline(text, truncated)
+		} else {
+			h.startMultiline(offset, text, truncated)
+		}
+		if !matches {
+			// This line didn't signal a continuation, so it's the last one.
+			if !h.flushMultiline(output, line, false) {
+				return false
+			}
+		}
+	default: // "after"
+		if h.mlPending && matches {
+			h.appendMultiline(text, truncated)
+		} else {
+			if !h.flushMultiline(output, line, false) {
+				return false
+			}
+			h.startMultiline(offset, text, truncated)
+		}
+	}
+
+	if h.mlPending && h.mlLines >= h.FileConfig.Multiline.MaxLines {
+		return h.flushMultiline(output, line, true)
+	}
+	return true
+}
+
+func (h *Harvester) startMultiline(offset int64, text string, truncated bool) {
+	h.mlPending = true
+	h.mlBuffer = append(h.mlBuffer[:0], text...)
+	h.mlLines = 1
+	h.mlStartOffset = offset
+	h.mlAnyTruncated = truncated
+	h.mlLastAppend = time.Now()
+}
+
+func (h *Harvester) appendMultiline(text string, truncated bool) {
+	h.mlBuffer = append(h.mlBuffer, '\n')
+	h.mlBuffer = append(h.mlBuffer, text...)
+	h.mlLines++
+	h.mlAnyTruncated = h.mlAnyTruncated || truncated
+	h.mlLastAppend = time.Now()
+}
+
+// flushMultiline ships the event under assembly, if any, and reports whether
+// it was actually sent (false means h.done fired first). multilineTruncated
+// marks an event cut short by hitting Multiline.MaxLines.
+func (h *Harvester) flushMultiline(output chan *FileEvent, line *uint64, multilineTruncated bool) bool {
+	if !h.mlPending {
+		return true
+	}
+
+	*line++
+	text := string(h.mlBuffer)
+	event := &FileEvent{
+		Source:             &h.Path,
+		Offset:             h.mlStartOffset,
+		Line:               *line,
+		Text:               &text,
+		Fields:             &h.FileConfig.Fields,
+		Truncated:          h.mlAnyTruncated,
+		MultilineTruncated: multilineTruncated,
+		Device:             h.device,
+		Inode:              h.inode,
+		fileinfo:           h.info,
+	}
+	sent := h.send(output, event)
+	if sent {
+		h.stats.recordShipped()
+	} else {
+		// Not delivered: rewind so h.FinishChan reports where this event
+		// starts and a resume reads it again.
+		h.Offset = h.mlStartOffset
+	}
+
+	h.mlPending = false
+	h.mlBuffer = h.mlBuffer[:0]
+	h.mlLines = 0
+	h.mlAnyTruncated = false
+
+	return sent
+}
+
+// multilineEnabled reports whether FileConfig.Multiline was configured with
+// a pattern; an unset pattern means multiline assembly is off entirely.
+func (h *Harvester) multilineEnabled() bool {
+	return h.FileConfig.Multiline.Pattern != nil
+}
+
+// applyMultilineDefaults fills in zero-valued multiline settings with the
+// package defaults, mirroring applyBackoffDefaults.
+func (h *Harvester) applyMultilineDefaults() {
+	if !h.multilineEnabled() {
+		return
+	}
+	if h.FileConfig.Multiline.MaxLines <= 0 {
+		h.FileConfig.Multiline.MaxLines = defaultMultilineMaxLines
+	}
+	if h.FileConfig.Multiline.Timeout <= 0 {
+		h.FileConfig.Multiline.Timeout = defaultMultilineTimeout
+	}
+	if h.FileConfig.Multiline.Match == "" {
+		h.FileConfig.Multiline.Match = defaultMultilineMatch
+	}
+}
+
+// drainRemaining flushes any complete lines still sitting in the decoded
+// reader's buffer before the fd is abandoned to a rotation, so data already
+// pulled off disk isn't lost. A trailing line with no terminator is left
+// behind; there's no safe way to know it's complete.
+func (h *Harvester) drainRemaining(output chan *FileEvent, line *uint64) {
+	for h.reader.Buffered() > 0 {
+		segment, err := h.reader.ReadBytes('\n')
+		if len(segment) == 0 || segment[len(segment)-1] != '\n' {
+			break
+		}
+
+		newlineLength := 1
+		if len(segment) > 1 && segment[len(segment)-2] == '\r' {
+			newlineLength++
+		}
+
+		*line++
+		text := string(segment[:len(segment)-newlineLength])
 		event := &FileEvent{
 			Source:   &h.Path,
 			Offset:   h.Offset,
-			Line:     line,
-			Text:     text,
+			Line:     *line,
+			Text:     &text,
 			Fields:   &h.FileConfig.Fields,
+			Device:   h.device,
+			Inode:    h.inode,
 			fileinfo: h.info,
 		}
-		h.Offset += int64(bytesread)
+		h.Offset += int64(len(segment))
+		if !h.send(output, event) {
+			return
+		}
+		h.stats.recordShipped()
 
-		output <- event // ship the new event downstream
-	} /* forever */
+		if err != nil {
+			break
+		}
+	}
+}
+
+// countingReader counts bytes read from the underlying file before decoding.
+// h.Offset is used directly as a raw file position (Seek on resume,
+// info.Size() comparisons for truncation), but h.reader sits on top of a
+// transform.Reader that may expand or shrink byte counts translating to
+// UTF-8 (UTF-16, GBK, latin1, ...). Placing this counter beneath the
+// transform keeps h.Offset tracking the real file position regardless of
+// encoding.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	nr, err := c.r.Read(p)
+	c.n += int64(nr)
+	return nr, err
 }
 
 func (h *Harvester) open() *os.File {
 	// Special handling that "-" means to read from standard input
 	if h.Path == "-" {
 		h.file = os.Stdin
+		h.rawReader = &countingReader{r: h.file, n: h.Offset}
+		h.reader = bufio.NewReader(transform.NewReader(h.rawReader, h.decoder.NewDecoder()))
 		return h.file
 	}
 
@@ -115,16 +583,96 @@ func (h *Harvester) open() *os.File {
 		h.IsTracked = true
 	}
 
+	if info, err := h.file.Stat(); err == nil {
+		h.device, h.inode = fileIdentity(info)
+		h.size = info.Size()
+		h.info = &info
+	}
+
+	h.rawReader = &countingReader{r: h.file, n: h.Offset}
+	h.reader = bufio.NewReader(transform.NewReader(h.rawReader, h.decoder.NewDecoder()))
+
 	return h.file
 }
 
-func (h *Harvester) readline(buffer *bytes.Buffer, eof_timeout time.Duration) (*string, int, error) {
+// checkRotation compares the open fd's identity against what's currently at
+// h.Path. A mismatch (or a path that no longer exists) means the file was
+// rotated out from under us: a rename (logrotate's default) or an unlink
+// lands here. In-place truncation (logrotate's copytruncate) keeps the same
+// file and is caught by checkTruncation instead.
+func (h *Harvester) checkRotation() (rotated bool, removed bool) {
+	if h.Path == "-" {
+		return false, false
+	}
+
+	fdInfo, err := h.file.Stat()
+	if err != nil {
+		return false, false
+	}
+
+	pathInfo, err := os.Stat(h.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, true
+		}
+		return false, false
+	}
+
+	return !os.SameFile(fdInfo, pathInfo), false
+}
+
+// checkTruncation reports whether the held fd has shrunk below our current
+// read offset, which is how in-place truncation (as opposed to rotation)
+// shows up once we no longer re-open the file on every read.
+func (h *Harvester) checkTruncation() bool {
+	info, err := h.file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Size() < h.Offset
+}
+
+// consumeRawOffset returns how many raw (pre-decode) bytes h.rawReader has
+// pulled from the underlying file since the line currently being assembled
+// started, and resets the baseline for whatever comes next. It's tracked off
+// h.lineRawStart -- a Harvester field, not a readline-local -- so a line that
+// straddles more than one readline call (a partial line still growing when
+// the EOF timeout fires) still reports its full raw length once it completes,
+// instead of under-counting to just the bytes read in the completing call.
+func (h *Harvester) consumeRawOffset() int {
+	n := h.rawReader.n - h.lineRawStart
+	h.lineRawStart = h.rawReader.n
+	return int(n)
+}
+
+// truncateUTF8 returns the longest prefix of b, at most max bytes, that ends
+// on a whole rune boundary. b is expected to already be valid UTF-8 (it came
+// out of h.decoder); trimming at an arbitrary byte offset for max_line_bytes
+// can otherwise split a multi-byte rune and emit invalid UTF-8.
+func truncateUTF8(b []byte, max int) []byte {
+	if max < 0 {
+		max = 0
+	}
+	if len(b) > max {
+		b = b[:max]
+	}
+	for len(b) > 0 && !utf8.Valid(b) {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func (h *Harvester) readline(buffer *bytes.Buffer, eof_timeout time.Duration) (*string, int, bool, error) {
 	var is_partial bool = true
 	var newline_length int = 1
+	var truncated bool = false
+	var discarding bool = false // past max_line_bytes; dropping bytes until the next newline
 	start_time := time.Now()
 
+	maxLineBytes := h.FileConfig.MaxLineBytes
+
 	for {
-		segment, err := h.read(int64(buffer.Len()))
+		segment, err := h.read()
 
 		if segment != nil && len(segment) > 0 {
 			if segment[len(segment)-1] == '\n' {
@@ -137,58 +685,144 @@ func (h *Harvester) readline(buffer *bytes.Buffer, eof_timeout time.Duration) (*
 				}
 			}
 
-			// TODO(sissel): if buffer exceeds a certain length, maybe report an error condition? chop it?
-			buffer.Write(segment)
+			if !discarding && maxLineBytes > 0 && buffer.Len()+len(segment) > maxLineBytes {
+				if room := maxLineBytes - buffer.Len(); room > 0 {
+					content := segment
+					if !is_partial {
+						content = segment[:len(segment)-newline_length]
+					}
+					buffer.Write(truncateUTF8(content, room))
+				}
+				truncated = true
+				discarding = true
+			} else if !discarding {
+				buffer.Write(segment)
+			}
+			// While discarding, h.rawReader has still advanced past the
+			// dropped bytes, so h.Offset (via consumeRawOffset) stays correct
+			// even though they never reach the buffer.
+
+			h.resetBackoff()
 		}
 
 		if err != nil {
 			if err == io.EOF && is_partial {
-				time.Sleep(1 * time.Second) // TODO(sissel): Implement backoff
+				if rotated, removed := h.checkRotation(); rotated || removed {
+					return nil, h.consumeRawOffset(), false, FILE_ROTATED
+				}
+				if h.checkTruncation() {
+					return nil, 0, false, FILE_TRUNCATED
+				}
+
+				h.sleepBackoff(eof_timeout - time.Since(start_time))
 
 				// Give up waiting for data after a certain amount of time.
-				// If we time out, return the error (eof)
+				// If we time out, return the error (eof). h.lineRawStart is
+				// left alone so a later call completing this same partial
+				// line still reports the bytes consumed here too.
 				if time.Since(start_time) > eof_timeout {
-					return nil, 0, err
+					return nil, 0, false, err
 				}
 				continue
 			} else {
 				emit("error: Harvester.readLine: %s", err.Error())
-				return nil, 0, err // TODO(sissel): don't do this?
+				return nil, 0, false, err // TODO(sissel): don't do this?
 			}
 		}
 
 		// If we got a full line, return the whole line without the EOL chars (CRLF or LF)
 		if !is_partial {
-			// Get the str length with the EOL chars (LF or CRLF)
-			bufferSize := buffer.Len()
+			bytesread := h.consumeRawOffset()
+
 			str := new(string)
-			*str = buffer.String()[:bufferSize-newline_length]
+			if truncated {
+				// The buffer was capped at max_line_bytes and never had the
+				// trailing EOL bytes appended to it, so it's already exactly
+				// the text to emit.
+				*str = buffer.String()
+			} else {
+				bufferSize := buffer.Len()
+				*str = buffer.String()[:bufferSize-newline_length]
+			}
 			// Reset the buffer for the next line
 			buffer.Reset()
-			return str, bufferSize, nil
+			return str, bytesread, truncated, nil
 		}
 	} /* forever read chunks */
+}
+
+// sleepBackoff waits h.backoffCurrent, capped at cap if that's sooner, then
+// grows h.backoffCurrent by FileConfig.BackoffFactor up to FileConfig.MaxBackoff
+// regardless of how long it actually slept. Called when a read comes back
+// empty (EOF) so a quiet file doesn't get polled at a fixed, possibly
+// CPU-wasteful rate. cap matters once backoffCurrent has grown close to
+// MaxBackoff (10s by default): without it, a pending multiline event's
+// eofTimeout deadline -- itself derived from the much shorter
+// Multiline.Timeout -- would always lose to a full backoff sleep, undercutting
+// that deadline by up to MaxBackoff.
+func (h *Harvester) sleepBackoff(cap time.Duration) {
+	sleep := h.backoffCurrent
+	if cap < sleep {
+		sleep = cap
+	}
+	if sleep > 0 {
+		time.Sleep(sleep)
+	}
+
+	h.backoffCurrent = time.Duration(float64(h.backoffCurrent) * h.FileConfig.BackoffFactor)
+	if h.backoffCurrent > h.FileConfig.MaxBackoff {
+		h.backoffCurrent = h.FileConfig.MaxBackoff
+	}
+}
 
-	return nil, 0, nil
+// resetBackoff drops the backoff delay back to its configured floor as soon
+// as data is read, so a single quiet patch doesn't leave the harvester slow
+// to notice the next write.
+func (h *Harvester) resetBackoff() {
+	h.backoffCurrent = h.FileConfig.Backoff
 }
 
-func (h *Harvester) read(offset int64) (line []byte, err error) {
-	h.open()
-	defer h.file.Close()
+// applyBackoffDefaults fills in zero-valued backoff settings with the
+// package defaults and seeds the running backoff delay.
+func (h *Harvester) applyBackoffDefaults() {
+	if h.FileConfig.Backoff <= 0 {
+		h.FileConfig.Backoff = defaultBackoff
+	}
+	if h.FileConfig.MaxBackoff <= 0 {
+		h.FileConfig.MaxBackoff = defaultMaxBackoff
+	}
+	if h.FileConfig.BackoffFactor <= 1 {
+		h.FileConfig.BackoffFactor = defaultBackoffFactor
+	}
+	h.backoffCurrent = h.FileConfig.Backoff
+}
 
-	info, _ := h.file.Stat()
-	defer func(size int64, info *os.FileInfo) {
-		h.size = size
-		h.info = info
-	}(info.Size(), &info)
-	if info.Size() < h.size {
-		return nil, FILE_TRUNCATED
+func (h *Harvester) read() (line []byte, err error) {
+	// h.reader decodes to UTF-8 before we ever see the bytes, so multi-byte
+	// encodings (UTF-16, Shift-JIS, GBK, ...) and their newline sequences are
+	// recognized correctly instead of being matched byte-by-byte. It's built
+	// in open(), rebuilt by readLoop after seeking back over a truncation, and
+	// otherwise lives for as long as the fd does.
+	return h.reader.ReadBytes('\n')
+}
+
+// resolveEncoding looks up h.FileConfig.Encoding against golang.org/x/text's
+// encoding registry. An empty encoding defaults to UTF-8. An unknown encoding
+// name is a fatal config error: silently falling back would corrupt every
+// line read from the file rather than just failing loudly at startup.
+func (h *Harvester) resolveEncoding() encoding.Encoding {
+	name := h.FileConfig.Encoding
+	if name == "" {
+		return encoding.Nop
 	}
 
-	h.file.Seek(offset, os.SEEK_CUR)
-	reader := bufio.NewReader(h.file)
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		emit("Fatal error: unable to resolve encoding %q for %q: %s\n", name, h.Path, err)
+		os.Exit(1)
+	}
 
-	return reader.ReadBytes('\n')
+	return enc
 }
 
 // panics
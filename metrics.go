@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// statusAddr, when set, serves /status and /metrics for every harvester
+// currently running. Borrowed from log-courier's "CalculateSpeed" idea:
+// without this there's zero visibility into which files are falling behind
+// beyond free-form emit() prints.
+var statusAddr = flag.String("status-addr", "", "address (e.g. :9090) to serve /status and /metrics on; disabled if empty")
+
+const (
+	statsTickInterval = 5 * time.Second
+	statsTau          = 60 * time.Second // EWMA time constant for lines/bytes per second
+)
+
+// harvesterStats holds the running counters and EWMA speeds for a single
+// harvested file, keyed by path in the package-level registry below.
+type harvesterStats struct {
+	mu sync.Mutex
+
+	Path string
+
+	Lines          uint64
+	Bytes          uint64
+	EventsShipped  uint64
+	LastOffset     int64
+	Size           int64
+	LastReadTime   time.Time
+	BackoffCurrent time.Duration
+
+	linesPerSec float64
+	bytesPerSec float64
+	prevLines   uint64
+	prevBytes   uint64
+	prevTick    time.Time
+}
+
+// recordRead accounts for one physical line pulled off disk. at is the
+// harvester's own last-read timestamp (see Harvester.touchLastRead) rather
+// than a fresh time.Now() here, so there's one clock, not two kept in sync
+// by convention.
+func (s *harvesterStats) recordRead(bytesread int, at time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Lines++
+	s.Bytes += uint64(bytesread)
+	s.LastReadTime = at
+	s.mu.Unlock()
+}
+
+func (s *harvesterStats) recordShipped() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.EventsShipped++
+	s.mu.Unlock()
+}
+
+func (s *harvesterStats) recordOffset(offset int64, backoff time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.LastOffset = offset
+	s.BackoffCurrent = backoff
+	s.mu.Unlock()
+}
+
+func (s *harvesterStats) recordSize(size int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Size = size
+	s.mu.Unlock()
+}
+
+// tick recomputes the EWMA lines/sec and bytes/sec: speed = alpha*instant +
+// (1-alpha)*prev, with alpha derived from the elapsed interval so a brief
+// stall doesn't tank the number the way a plain moving average would.
+func (s *harvesterStats) tick(now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.prevTick.IsZero() {
+		s.prevTick = now
+		s.prevLines = s.Lines
+		s.prevBytes = s.Bytes
+		return
+	}
+
+	dt := now.Sub(s.prevTick).Seconds()
+	if dt <= 0 {
+		return
+	}
+
+	instantLines := float64(s.Lines-s.prevLines) / dt
+	instantBytes := float64(s.Bytes-s.prevBytes) / dt
+	alpha := 1 - math.Exp(-dt/statsTau.Seconds())
+
+	s.linesPerSec = alpha*instantLines + (1-alpha)*s.linesPerSec
+	s.bytesPerSec = alpha*instantBytes + (1-alpha)*s.bytesPerSec
+
+	s.prevLines = s.Lines
+	s.prevBytes = s.Bytes
+	s.prevTick = now
+}
+
+// harvesterStatsSnapshot is the JSON/Prometheus-facing view of harvesterStats.
+type harvesterStatsSnapshot struct {
+	Path           string  `json:"path"`
+	Lines          uint64  `json:"lines"`
+	Bytes          uint64  `json:"bytes"`
+	EventsShipped  uint64  `json:"events_shipped"`
+	Offset         int64   `json:"offset"`
+	Size           int64   `json:"size"`
+	Lag            int64   `json:"lag"`
+	LinesPerSecond float64 `json:"lines_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	BackoffCurrent string  `json:"backoff_current"`
+	State          string  `json:"state"`
+}
+
+func (s *harvesterStats) snapshot() harvesterStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := "idle"
+	if !s.LastReadTime.IsZero() && time.Since(s.LastReadTime) < 2*statsTickInterval {
+		state = "active"
+	}
+
+	return harvesterStatsSnapshot{
+		Path:           s.Path,
+		Lines:          s.Lines,
+		Bytes:          s.Bytes,
+		EventsShipped:  s.EventsShipped,
+		Offset:         s.LastOffset,
+		Size:           s.Size,
+		Lag:            s.Size - s.LastOffset,
+		LinesPerSecond: s.linesPerSec,
+		BytesPerSecond: s.bytesPerSec,
+		BackoffCurrent: s.BackoffCurrent.String(),
+		State:          state,
+	}
+}
+
+// statsRegistry is the package-level home for every harvester's stats,
+// keyed by the path it's harvesting.
+var statsRegistry = struct {
+	mu     sync.Mutex
+	byPath map[string]*harvesterStats
+}{byPath: make(map[string]*harvesterStats)}
+
+func registerHarvesterStats(path string) *harvesterStats {
+	s := &harvesterStats{Path: path}
+
+	statsRegistry.mu.Lock()
+	statsRegistry.byPath[path] = s
+	statsRegistry.mu.Unlock()
+
+	return s
+}
+
+// unregisterHarvesterStats removes s, but only if it's still the entry
+// registered for path. During a rotation handoff the old harvester's deferred
+// unregister can otherwise race a new harvester already registered at the
+// same path (the common case, since FileConfig.CloseRenamed/CloseRemoved
+// exist precisely so a new harvester can start before the old one finishes
+// draining) and delete the new one's live stats instead of its own.
+func unregisterHarvesterStats(path string, s *harvesterStats) {
+	statsRegistry.mu.Lock()
+	if statsRegistry.byPath[path] == s {
+		delete(statsRegistry.byPath, path)
+	}
+	statsRegistry.mu.Unlock()
+}
+
+func snapshotAllStats() []harvesterStatsSnapshot {
+	statsRegistry.mu.Lock()
+	defer statsRegistry.mu.Unlock()
+
+	snapshots := make([]harvesterStatsSnapshot, 0, len(statsRegistry.byPath))
+	for _, s := range statsRegistry.byPath {
+		snapshots = append(snapshots, s.snapshot())
+	}
+	return snapshots
+}
+
+// StartStatusServer serves GET /status (JSON) and GET /metrics (Prometheus
+// text) on *statusAddr. It's a no-op if --status-addr wasn't set. Call once
+// after flag.Parse().
+func StartStatusServer() {
+	if *statusAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/metrics", handleMetrics)
+
+	go func() {
+		if err := http.ListenAndServe(*statusAddr, mux); err != nil {
+			emit("status server on %s failed: %s\n", *statusAddr, err)
+		}
+	}()
+
+	emit("status server listening on %s\n", *statusAddr)
+}
+
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshotAllStats())
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	snapshots := snapshotAllStats()
+
+	fmt.Fprintln(w, "# HELP logstash_forwarder_lines_total Physical lines read from the source file.")
+	fmt.Fprintln(w, "# TYPE logstash_forwarder_lines_total counter")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "logstash_forwarder_lines_total{path=%q} %d\n", s.Path, s.Lines)
+	}
+
+	fmt.Fprintln(w, "# HELP logstash_forwarder_bytes_total Bytes read from the source file.")
+	fmt.Fprintln(w, "# TYPE logstash_forwarder_bytes_total counter")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "logstash_forwarder_bytes_total{path=%q} %d\n", s.Path, s.Bytes)
+	}
+
+	fmt.Fprintln(w, "# HELP logstash_forwarder_events_shipped_total Events sent downstream.")
+	fmt.Fprintln(w, "# TYPE logstash_forwarder_events_shipped_total counter")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "logstash_forwarder_events_shipped_total{path=%q} %d\n", s.Path, s.EventsShipped)
+	}
+
+	fmt.Fprintln(w, "# HELP logstash_forwarder_lag_bytes Bytes of the source file not yet read.")
+	fmt.Fprintln(w, "# TYPE logstash_forwarder_lag_bytes gauge")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "logstash_forwarder_lag_bytes{path=%q} %d\n", s.Path, s.Lag)
+	}
+
+	fmt.Fprintln(w, "# HELP logstash_forwarder_lines_per_second EWMA of lines read per second.")
+	fmt.Fprintln(w, "# TYPE logstash_forwarder_lines_per_second gauge")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "logstash_forwarder_lines_per_second{path=%q} %f\n", s.Path, s.LinesPerSecond)
+	}
+
+	fmt.Fprintln(w, "# HELP logstash_forwarder_bytes_per_second EWMA of bytes read per second.")
+	fmt.Fprintln(w, "# TYPE logstash_forwarder_bytes_per_second gauge")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "logstash_forwarder_bytes_per_second{path=%q} %f\n", s.Path, s.BytesPerSecond)
+	}
+}